@@ -0,0 +1,190 @@
+// Use of this source code is governed by a BSD-style license that can be found
+// in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	a "github.com/google/puffs/lang/ast"
+	t "github.com/google/puffs/lang/token"
+)
+
+// Backend is the set of language-specific hooks that an AST walk needs in
+// order to emit a target language. cmd/puffs-gen-c implements this
+// interface for C, via cBackend below; a future cmd/puffs-gen-go (or a
+// WASM or Rust backend) would provide another implementation and reuse the
+// AST traversal, jump-target numbering and visibility filtering that
+// already lives in gen (see main.go), instead of forking it.
+//
+// TODO: moving Backend itself into lang/generate, so that generate.Main
+// can take a Backend instead of today's raw callback, and adding a second
+// implementation (e.g. for a cmd/puffs-gen-go) to actually exercise this
+// indirection, is follow-up work gated on that package (not present in
+// this checkout) being editable here. For now this is a C-only shim: it
+// lets gen's methods stop hard-coding cTypeNames/cOpNames lookups inline,
+// without yet proving the interface is backend-agnostic.
+type Backend interface {
+	// EmitType returns the target-language spelling of a Puffs type.
+	EmitType(x *a.TypeExpr) (string, error)
+	// EmitOp returns the target-language spelling of a Puffs operator.
+	EmitOp(key t.Key) string
+	// EmitStatus returns the target-language declaration (e.g. one arm of
+	// a C enum) for a built-in status value.
+	EmitStatus(name string, code int32) string
+	// EmitFuncPrologue writes a function's entry sequence: whatever checks
+	// on the receiver, previous status and arguments the target language's
+	// ABI needs before the body runs. It reports whether it emitted a
+	// cleanup-style early-exit label, so that EmitFuncEpilogue (called
+	// later for the same n) knows whether to close one.
+	EmitFuncPrologue(n *a.Func) (cleanup0 bool, err error)
+	// EmitFuncEpilogue writes a function's exit sequence. cleanup0 is
+	// whatever EmitFuncPrologue returned for the same n.
+	EmitFuncEpilogue(n *a.Func, cleanup0 bool)
+	// EmitStructPrologue writes whatever target-language fields must
+	// precede a struct's Puffs-declared fields, e.g. C's status/magic
+	// header that every suspendible struct needs for ABI compatibility
+	// (see writeStruct's doc comment).
+	EmitStructPrologue(n *a.Struct)
+	// FormatterCmd is the external command (if any) to pipe the emitted
+	// source through, e.g. ["clang-format", "-style=Chromium"] for C. A
+	// nil or empty slice means: don't post-process, emit as-is.
+	FormatterCmd() []string
+}
+
+// cBackend is puffs-gen-c's Backend implementation.
+type cBackend struct {
+	g *gen
+}
+
+var _ Backend = cBackend{}
+
+func (b cBackend) EmitType(x *a.TypeExpr) (string, error) {
+	if base, _ := typeDecorators(x); base != nil {
+		if k := base.Name().Key(); k < t.Key(len(cTypeNames)) {
+			if s := cTypeNames[k]; s != "" {
+				return s, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cannot convert Puffs type %q to C", x.String(b.g.idMap))
+}
+
+func (b cBackend) EmitOp(key t.Key) string {
+	return cOpNames[0xFF&key]
+}
+
+func (b cBackend) EmitStatus(name string, code int32) string {
+	nudge := ""
+	if strings.HasPrefix(name, "error_") {
+		nudge = "+1"
+	}
+	return fmt.Sprintf("puffs_%s_%s = %d%s", b.g.pkgName, name, code, nudge)
+}
+
+func (b cBackend) EmitFuncPrologue(n *a.Func) (cleanup0 bool, err error) {
+	g := b.g
+	if n.Public() {
+		if n.Receiver() != 0 {
+			g.printf("if (!self) { return puffs_%s_error_bad_receiver; }\n", g.pkgName)
+		}
+	}
+	if n.Suspendible() {
+		g.printf("puffs_%s_status status = ", g.pkgName)
+		if n.Receiver() != 0 {
+			g.printf("self->status;\n")
+			if n.Public() {
+				g.printf("if (status & 1) { return status; }")
+			}
+		} else {
+			g.printf("puffs_%s_status_ok;\n", g.pkgName)
+		}
+		if n.Public() {
+			g.printf("if (self->magic != PUFFS_MAGIC) {"+
+				"status = puffs_%s_error_constructor_not_called; goto cleanup0; }\n", g.pkgName)
+			cleanup0 = true
+		}
+	} else if r := n.Receiver(); r != 0 {
+		// TODO: fix this.
+		return false, fmt.Errorf(`cannot convert Puffs function "%s.%s" to C`,
+			r.String(g.idMap), n.Name().String(g.idMap))
+	}
+	if n.Public() {
+		badArg := false
+		for _, o := range n.In().Fields() {
+			o := o.Field()
+			if o.XType().PackageOrDecorator().Key() != t.KeyPtr {
+				// TODO: check for type refinements: u32[..4095] instead of
+				// u32. Also check for types, for array-typed arguments.
+				continue
+			}
+			if badArg {
+				g.writes(" || ")
+			} else {
+				g.writes("if (")
+			}
+			g.printf("!a_%s", o.Name().String(g.idMap))
+			badArg = true
+		}
+		if badArg {
+			g.writes(") {")
+			if n.Suspendible() {
+				g.printf("status = puffs_%s_error_bad_argument; goto cleanup0; }\n", g.pkgName)
+			} else {
+				g.printf("return puffs_%s_error_bad_argument; }\n", g.pkgName)
+			}
+		}
+	}
+	return cleanup0, nil
+}
+
+func (b cBackend) EmitFuncEpilogue(n *a.Func, cleanup0 bool) {
+	g := b.g
+	if cleanup0 {
+		g.printf("cleanup0: self->status = status;\n")
+	}
+	if n.Suspendible() {
+		b.emitGuardedBufferAsserts(n)
+		g.printf("return status;\n")
+	}
+}
+
+// emitGuardedBufferAsserts optionally re-checks, for every buf1-typed
+// pointer argument of a suspendible function, the wi <= len invariant that
+// a WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS build's guarded allocator is meant
+// to catch violations of (see base-impl's "Guarded Buffers" section). It
+// compiles away to nothing otherwise. EmitFuncEpilogue calls this right
+// before a suspendible function returns its status, since that return may
+// be a short_read/short_dst suspension.
+func (b cBackend) emitGuardedBufferAsserts(n *a.Func) {
+	g := b.g
+	var names []string
+	for _, o := range n.In().Fields() {
+		o := o.Field()
+		if base, nPtr := typeDecorators(o.XType()); base != nil && nPtr > 0 && base.Name().Key() == t.KeyBuf1 {
+			names = append(names, o.Name().String(g.idMap))
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	g.writes("#if defined(WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS)\n")
+	for _, name := range names {
+		g.printf("if (a_%s) { assert(a_%s->wi <= a_%s->len); }\n", name, name, name)
+	}
+	g.writes("#endif\n")
+}
+
+func (b cBackend) EmitStructPrologue(n *a.Struct) {
+	if !n.Suspendible() {
+		return
+	}
+	g := b.g
+	g.printf("puffs_%s_status status;\n", g.pkgName)
+	g.printf("uint32_t magic;\n")
+}
+
+func (b cBackend) FormatterCmd() []string {
+	return []string{"clang-format", "-style=Chromium"}
+}
@@ -31,11 +31,16 @@ func main() {
 			idMap:   idMap,
 			files:   files,
 		}
+		g.backend = cBackend{g: g}
 		if err := g.generate(); err != nil {
 			return nil, err
 		}
 		stdout := &bytes.Buffer{}
-		cmd := exec.Command("clang-format", "-style=Chromium")
+		formatterCmd := g.backend.FormatterCmd()
+		if len(formatterCmd) == 0 {
+			return g.buffer.Bytes(), nil
+		}
+		cmd := exec.Command(formatterCmd[0], formatterCmd[1:]...)
 		cmd.Stdin = &g.buffer
 		cmd.Stdout = stdout
 		cmd.Stderr = os.Stderr
@@ -76,6 +81,7 @@ type gen struct {
 	idMap       *t.IDMap
 	files       []*a.File
 	jumpTargets map[*a.While]uint32
+	backend     Backend
 }
 
 func (g *gen) printf(format string, args ...interface{}) { fmt.Fprintf(&g.buffer, format, args...) }
@@ -111,11 +117,8 @@ func (g *gen) generate() error {
 	g.writes("// The least significant bit indicates a non-recoverable status code: an error.\n")
 	g.writes("typedef enum {\n")
 	for i, s := range builtInStatuses {
-		nudge := ""
-		if strings.HasPrefix(s, "error_") {
-			nudge = "+1"
-		}
-		g.printf("puffs_%s_%s = %d%s,\n", g.pkgName, s, -2*i, nudge)
+		g.writes(g.backend.EmitStatus(s, int32(-2*i)))
+		g.writes(",\n")
 	}
 	g.printf("} puffs_%s_status;\n\n", g.pkgName)
 	g.printf("bool puffs_%s_status_is_error(puffs_%s_status s);\n\n", g.pkgName, g.pkgName)
@@ -244,10 +247,7 @@ func (g *gen) writeStruct(n *a.Struct) error {
 	// different versions.
 	structName := n.Name().String(g.idMap)
 	g.printf("typedef struct {\n")
-	if n.Suspendible() {
-		g.printf("puffs_%s_status status;\n", g.pkgName)
-		g.printf("uint32_t magic;\n")
-	}
+	g.backend.EmitStructPrologue(n)
 	for _, f := range n.Fields() {
 		if err := g.writeField(f.Field(), "f_"); err != nil {
 			return err
@@ -372,6 +372,30 @@ func (g *gen) writeFuncPrototype(n *a.Func) error {
 	return nil
 }
 
+// typeDecorators walks past a Puffs type's KeyPtr/KeyOpenBracket decorators
+// down to its undecorated base type, reporting how many KeyPtr levels it
+// walked through. It returns a nil base if x is decorated by anything else
+// (e.g. a package qualifier), since that's not a shape this package's
+// EmitType or C declarator logic knows how to handle. EmitType,
+// writeTypeAndName and EmitFuncEpilogue's guarded-buffer-assert check all
+// need this same walk, so it lives here rather than being copied three
+// times.
+func typeDecorators(x *a.TypeExpr) (base *a.TypeExpr, nPtr int) {
+	for ; x != nil; x = x.Inner() {
+		p := x.PackageOrDecorator().Key()
+		if p == t.KeyPtr {
+			nPtr++
+			continue
+		} else if p == t.KeyOpenBracket {
+			continue
+		} else if p != 0 {
+			return nil, 0
+		}
+		return x, nPtr
+	}
+	return nil, 0
+}
+
 func (g *gen) writeFuncImpl(n *a.Func) error {
 	g.jumpTargets = nil
 	if err := g.writeFuncSignature(n); err != nil {
@@ -379,59 +403,10 @@ func (g *gen) writeFuncImpl(n *a.Func) error {
 	}
 	g.writes("{\n")
 
-	cleanup0 := false
-
 	// Check the previous status and the args.
-	if n.Public() {
-		if n.Receiver() != 0 {
-			g.printf("if (!self) { return puffs_%s_error_bad_receiver; }\n", g.pkgName)
-		}
-	}
-	if n.Suspendible() {
-		g.printf("puffs_%s_status status = ", g.pkgName)
-		if n.Receiver() != 0 {
-			g.printf("self->status;\n")
-			if n.Public() {
-				g.printf("if (status & 1) { return status; }")
-			}
-		} else {
-			g.printf("puffs_%s_status_ok;\n", g.pkgName)
-		}
-		if n.Public() {
-			g.printf("if (self->magic != PUFFS_MAGIC) {"+
-				"status = puffs_%s_error_constructor_not_called; goto cleanup0; }\n", g.pkgName)
-			cleanup0 = true
-		}
-	} else if r := n.Receiver(); r != 0 {
-		// TODO: fix this.
-		return fmt.Errorf(`cannot convert Puffs function "%s.%s" to C`,
-			r.String(g.idMap), n.Name().String(g.idMap))
-	}
-	if n.Public() {
-		badArg := false
-		for _, o := range n.In().Fields() {
-			o := o.Field()
-			if o.XType().PackageOrDecorator().Key() != t.KeyPtr {
-				// TODO: check for type refinements: u32[..4095] instead of
-				// u32. Also check for types, for array-typed arguments.
-				continue
-			}
-			if badArg {
-				g.writes(" || ")
-			} else {
-				g.writes("if (")
-			}
-			g.printf("!a_%s", o.Name().String(g.idMap))
-			badArg = true
-		}
-		if badArg {
-			g.writes(") {")
-			if n.Suspendible() {
-				g.printf("status = puffs_%s_error_bad_argument; goto cleanup0; }\n", g.pkgName)
-			} else {
-				g.printf("return puffs_%s_error_bad_argument; }\n", g.pkgName)
-			}
-		}
+	cleanup0, err := g.backend.EmitFuncPrologue(n)
+	if err != nil {
+		return err
 	}
 	g.writes("\n")
 
@@ -449,59 +424,44 @@ func (g *gen) writeFuncImpl(n *a.Func) error {
 	}
 	g.writes("\n")
 
-	if cleanup0 {
-		g.printf("cleanup0: self->status = status;\n")
-	}
-	if n.Suspendible() {
-		g.printf("return status;\n")
-	}
+	g.backend.EmitFuncEpilogue(n, cleanup0)
 
 	g.writes("}\n\n")
 	return nil
 }
 
 func (g *gen) writeField(n *a.Field, namePrefix string) error {
+	return g.writeTypeAndName(n.XType(), namePrefix+n.Name().String(g.idMap))
+}
+
+// writeTypeAndName writes a C declarator for a Puffs-typed field or local
+// variable, e.g. "uint8_t *foo" or "uint8_t foo[256]": the pointee type,
+// leading '*'s for each KeyPtr decorator, name, and trailing '[N]'s for each
+// KeyOpenBracket decorator. writeField and writeVars share this so that a
+// local variable's pointer depth and array length are never dropped.
+func (g *gen) writeTypeAndName(x *a.TypeExpr, name string) error {
 	const maxNPtr = 16
 
-	convertible, nPtr := true, 0
-	for x := n.XType(); x != nil; x = x.Inner() {
-		if p := x.PackageOrDecorator().Key(); p == t.KeyPtr {
-			if nPtr == maxNPtr {
-				return fmt.Errorf("cannot convert Puffs type %q to C: too many ptr's", n.XType().String(g.idMap))
-			}
-			nPtr++
-			continue
-		} else if p == t.KeyOpenBracket {
-			continue
-		} else if p != 0 {
-			convertible = false
-			break
-		}
-		if k := x.Name().Key(); k < t.Key(len(cTypeNames)) {
-			if s := cTypeNames[k]; s != "" {
-				g.writes(s)
-				g.writeb(' ')
-				continue
-			}
-		}
-		convertible = false
-		break
+	_, nPtr := typeDecorators(x)
+	if nPtr > maxNPtr {
+		return fmt.Errorf("cannot convert Puffs type %q to C: too many ptr's", x.String(g.idMap))
 	}
-	if !convertible {
-		// TODO: fix this.
-		return fmt.Errorf("cannot convert Puffs type %q to C", n.XType().String(g.idMap))
+	typeName, err := g.backend.EmitType(x)
+	if err != nil {
+		return err
 	}
+	g.writes(typeName)
+	g.writeb(' ')
 
 	for i := 0; i < nPtr; i++ {
 		g.writeb('*')
 	}
-	g.writes(namePrefix)
-	g.writes(n.Name().String(g.idMap))
+	g.writes(name)
 
-	for x := n.XType(); x != nil; x = x.Inner() {
-		if x.PackageOrDecorator() == t.IDOpenBracket {
+	for y := x; y != nil; y = y.Inner() {
+		if y.PackageOrDecorator() == t.IDOpenBracket {
 			g.writeb('[')
-			g.writes(x.ArrayLength().ConstValue().String())
+			g.writes(y.ArrayLength().ConstValue().String())
 			g.writeb(']')
 		}
 	}
@@ -517,14 +477,12 @@ func (g *gen) writeVars(n *a.Node, depth uint32) error {
 
 	if n.Kind() == a.KVar {
 		x := n.Var().XType()
-		if k := x.Name().Key(); k < t.Key(len(cTypeNames)) {
-			if s := cTypeNames[k]; s != "" {
-				g.printf("%s v_%s;\n", s, n.Var().Name().String(g.idMap))
-				return nil
-			}
+		name := n.Var().Name().String(g.idMap)
+		if err := g.writeTypeAndName(x, "v_"+name); err != nil {
+			return err
 		}
-		// TODO: fix this.
-		return fmt.Errorf("cannot convert Puffs type %q to C", x.String(g.idMap))
+		g.writes(";\n")
+		return nil
 	}
 
 	for _, l := range n.Raw().SubLists() {
@@ -554,7 +512,7 @@ func (g *gen) writeStatement(n *a.Node, depth uint32) error {
 			return err
 		}
 		// TODO: does KeyAmpHatEq need special consideration?
-		g.writes(cOpNames[0xFF&n.Operator().Key()])
+		g.writes(g.backend.EmitOp(0xFF & n.Operator().Key()))
 		if err := g.writeExpr(n.RHS(), depth); err != nil {
 			return err
 		}
@@ -714,7 +672,7 @@ func (g *gen) writeExprBinaryOp(n *a.Expr, depth uint32) error {
 		return err
 	}
 	// TODO: does KeyXBinaryAmpHat need special consideration?
-	g.writes(cOpNames[0xFF&op.Key()])
+	g.writes(g.backend.EmitOp(0xFF & op.Key()))
 	if err := g.writeExpr(n.RHS().Expr(), depth); err != nil {
 		return err
 	}
@@ -17,28 +17,46 @@
 package cgen
 
 const baseHeader = "" +
-	"#ifndef WUFFS_BASE_HEADER_H\n#define WUFFS_BASE_HEADER_H\n\n// Copyright 2017 The Wuffs Authors.\n//\n// Licensed under the Apache License, Version 2.0 (the \"License\");\n// you may not use this file except in compliance with the License.\n// You may obtain a copy of the License at\n//\n//    https://www.apache.org/licenses/LICENSE-2.0\n//\n// Unless required by applicable law or agreed to in writing, software\n// distributed under the License is distributed on an \"AS IS\" BASIS,\n// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.\n// See the License for the specific language governing permissions and\n// limitations under the License.\n\n#include <stdbool.h>\n#include <stdint.h>\n#include <string.h>\n\n// Wuffs requires a word size of at least 32 bits because it assumes that\n// converting a u32 to usize will never overflow. For example, the size of a\n// decoded image is often represented, explicitly or implicitly in an image\n// file, as a u32, and it is convenient to compare that to a buffer size.\n//\n// Si" +
-	"milarly, the word size is at most 64 bits because it assumes that\n// converting a usize to u64 will never overflow.\n#if __WORDSIZE < 32\n#error \"Wuffs requires a word size of at least 32 bits\"\n#elif __WORDSIZE > 64\n#error \"Wuffs requires a word size of at most 64 bits\"\n#endif\n\n// WUFFS_VERSION is the major.minor version number as a uint32. The major\n// number is the high 16 bits. The minor number is the low 16 bits.\n//\n// The intention is to bump the version number at least on every API / ABI\n// backwards incompatible change.\n//\n// For now, the API and ABI are simply unstable and can change at any time.\n//\n// TODO: don't hard code this in base-header.h.\n#define WUFFS_VERSION (0x00001)\n\n// ---------------- I/O\n\n// wuffs_base__slice_u8 is a 1-dimensional buffer (a pointer and length).\n//\n// A value with all fields NULL or zero is a valid, empty slice.\ntypedef struct {\n  uint8_t* ptr;\n  size_t len;\n} wuffs_base__slice_u8;\n\n// wuffs_base__buf1 is a 1-dimensional buffer (a pointer and length), plus\n// additional in" +
-	"dexes into that buffer, plus an opened / closed flag.\n//\n// A value with all fields NULL or zero is a valid, empty buffer.\ntypedef struct {\n  uint8_t* ptr;  // Pointer.\n  size_t len;    // Length.\n  size_t wi;     // Write index. Invariant: wi <= len.\n  size_t ri;     // Read  index. Invariant: ri <= wi.\n  bool closed;   // No further writes are expected.\n} wuffs_base__buf1;\n\n// wuffs_base__limit1 provides a limited view of a 1-dimensional byte stream:\n// its first N bytes. That N can be greater than a buffer's current read or\n// write capacity. N decreases naturally over time as bytes are read from or\n// written to the stream.\n//\n// A value with all fields NULL or zero is a valid, unlimited view.\ntypedef struct wuffs_base__limit1 {\n  uint64_t* ptr_to_len;             // Pointer to N.\n  struct wuffs_base__limit1* next;  // Linked list of limits.\n} wuffs_base__limit1;\n\ntypedef struct {\n  // TODO: move buf into private_impl? As it is, it looks like users can modify\n  // the buf field to point to a different buf" +
-	"fer, which can turn the limit and\n  // mark fields into dangling pointers.\n  wuffs_base__buf1* buf;\n  // Do not access the private_impl's fields directly. There is no API/ABI\n  // compatibility or safety guarantee if you do so.\n  struct {\n    wuffs_base__limit1 limit;\n    uint8_t* mark;\n  } private_impl;\n} wuffs_base__reader1;\n\ntypedef struct {\n  // TODO: move buf into private_impl? As it is, it looks like users can modify\n  // the buf field to point to a different buffer, which can turn the limit and\n  // mark fields into dangling pointers.\n  wuffs_base__buf1* buf;\n  // Do not access the private_impl's fields directly. There is no API/ABI\n  // compatibility or safety guarantee if you do so.\n  struct {\n    wuffs_base__limit1 limit;\n    uint8_t* mark;\n  } private_impl;\n} wuffs_base__writer1;\n\n// ---------------- Images\n\ntypedef struct {\n  // Do not access the private_impl's fields directly. There is no API/ABI\n  // compatibility or safety guarantee if you do so.\n  struct {\n    uint32_t flags;\n    uint32_t w;\n " +
-	"   uint32_t h;\n    // TODO: color model, including both packed RGBA and planar,\n    // chroma-subsampled YCbCr.\n  } private_impl;\n} wuffs_base__image_config;\n\nstatic inline void wuffs_base__image_config__invalidate(\n    wuffs_base__image_config* c) {\n  if (c) {\n    *c = ((wuffs_base__image_config){});\n  }\n}\n\nstatic inline bool wuffs_base__image_config__valid(\n    wuffs_base__image_config* c) {\n  if (!c || !(c->private_impl.flags & 1)) {\n    return false;\n  }\n  uint64_t wh = ((uint64_t)c->private_impl.w) * ((uint64_t)c->private_impl.h);\n  // TODO: handle things other than 1 byte per pixel.\n  return wh <= ((uint64_t)SIZE_MAX);\n}\n\nstatic inline uint32_t wuffs_base__image_config__width(\n    wuffs_base__image_config* c) {\n  return wuffs_base__image_config__valid(c) ? c->private_impl.w : 0;\n}\n\nstatic inline uint32_t wuffs_base__image_config__height(\n    wuffs_base__image_config* c) {\n  return wuffs_base__image_config__valid(c) ? c->private_impl.h : 0;\n}\n\n// TODO: this is the right API for planar (not packed) pixbuf" +
-	"s? Should it allow\n// decoding into a color model different from the format's intrinsic one? For\n// example, decoding a JPEG image straight to RGBA instead of to YCbCr?\nstatic inline size_t wuffs_base__image_config__pixbuf_size(\n    wuffs_base__image_config* c) {\n  if (wuffs_base__image_config__valid(c)) {\n    uint64_t wh = ((uint64_t)c->private_impl.w) * ((uint64_t)c->private_impl.h);\n    // TODO: handle things other than 1 byte per pixel.\n    return (size_t)wh;\n  }\n  return 0;\n}\n\nstatic inline void wuffs_base__image_config__initialize(\n    wuffs_base__image_config* c,\n    uint32_t width,\n    uint32_t height,\n    uint32_t TODO_color_model) {\n  if (!c) {\n    return;\n  }\n  c->private_impl.flags = 1;\n  c->private_impl.w = width;\n  c->private_impl.h = height;\n  // TODO: color model.\n}\n\n#endif  // WUFFS_BASE_HEADER_H\n" +
-	""
+	"#ifndef WUFFS_BASE_HEADER_H\n#define WUFFS_BASE_HEADER_H\n\n// Copyright 2017 The Wuffs Authors.\n//\n// Licensed under the Apache License, Version 2.0 (the \"License\");\n// you may not use this file except in compliance with the License.\n// You may obtain a copy of the License at\n//\n//    https://www.apache.org/licenses/LICENSE-2.0\n//\n// Unless required by applicable law or agreed to in writing, software\n// distributed under the License is distributed on an \"AS IS\" BASIS,\n// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.\n// See the License for the specific language governing permissions and\n// limitations under the License.\n\n#include <stdbool.h>\n#include <stdint.h>\n#include <string.h>\n\n// Wuffs requires a word size of at least 32 bits because it assumes that\n// converting a u32 to usize will never overflow. For example, the size of a\n// deco" +
+	"ded image is often represented, explicitly or implicitly in an image\n// file, as a u32, and it is convenient to compare that to a buffer size.\n//\n// Similarly, the word size is at most 64 bits because it assumes that\n// converting a usize to u64 will never overflow.\n#if __WORDSIZE < 32\n#error \"Wuffs requires a word size of at least 32 bits\"\n#elif __WORDSIZE > 64\n#error \"Wuffs requires a word size of at most 64 bits\"\n#endif\n\n// WUFFS_VERSION is the major.minor version number as a uint32. The major\n// number is the high 16 bits. The minor number is the low 16 bits.\n//\n// The intention is to bump the version number at least on every API / ABI\n// backwards incompatible change.\n//\n// For now, the API and ABI are simply unstable and can change at any time.\n//\n// TODO: don't hard code this in base-header.h.\n#define WUFFS_VERSION (0x00001)\n\n// ---------------- I/O\n\n//" +
+	" wuffs_base__slice_u8 is a 1-dimensional buffer (a pointer and length).\n//\n// A value with all fields NULL or zero is a valid, empty slice.\ntypedef struct {\n  uint8_t* ptr;\n  size_t len;\n} wuffs_base__slice_u8;\n\n// wuffs_base__buf1 is a 1-dimensional buffer (a pointer and length), plus\n// additional indexes into that buffer, plus an opened / closed flag.\n//\n// A value with all fields NULL or zero is a valid, empty buffer.\ntypedef struct {\n  uint8_t* ptr;  // Pointer.\n  size_t len;    // Length.\n  size_t wi;     // Write index. Invariant: wi <= len.\n  size_t ri;     // Read  index. Invariant: ri <= wi.\n  bool closed;   // No further writes are expected.\n} wuffs_base__buf1;\n\n// wuffs_base__limit1 provides a limited view of a 1-dimensional byte stream:\n// its first N bytes. That N can be greater than a buffer's current read or\n// write capacity. N decreases naturally o" +
+	"ver time as bytes are read from or\n// written to the stream.\n//\n// A value with all fields NULL or zero is a valid, unlimited view.\ntypedef struct wuffs_base__limit1 {\n  uint64_t* ptr_to_len;             // Pointer to N.\n  struct wuffs_base__limit1* next;  // Linked list of limits.\n} wuffs_base__limit1;\n\ntypedef struct {\n  // TODO: move buf into private_impl? As it is, it looks like users can modify\n  // the buf field to point to a different buffer, which can turn the limit and\n  // mark fields into dangling pointers.\n  wuffs_base__buf1* buf;\n  // Do not access the private_impl's fields directly. There is no API/ABI\n  // compatibility or safety guarantee if you do so.\n  struct {\n    wuffs_base__limit1 limit;\n    uint8_t* mark;\n  } private_impl;\n} wuffs_base__reader1;\n\ntypedef struct {\n  // TODO: move buf into private_impl? As it is, it looks like users can modify\n" +
+	"  // the buf field to point to a different buffer, which can turn the limit and\n  // mark fields into dangling pointers.\n  wuffs_base__buf1* buf;\n  // Do not access the private_impl's fields directly. There is no API/ABI\n  // compatibility or safety guarantee if you do so.\n  struct {\n    wuffs_base__limit1 limit;\n    uint8_t* mark;\n  } private_impl;\n} wuffs_base__writer1;\n\n// ---------------- Images\n\n// wuffs_base__pixel_format is a packed pixel format descriptor. Its bits:\n//  - bit        31: 1 if planar (e.g. YCbCr), 0 if packed (e.g. RGBA).\n//  - bit        30: 1 if alpha is premultiplied into the color channels.\n//  - bits 16 .. 29: reserved for future use. Must be zero.\n//  - bits  8 .. 15: bits per channel sample (e.g. 8, 10, 16).\n//  - bits  0 ..  7: a WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__* value.\ntypedef uint32_t wuffs_base__pixel_format;\n\n#define WUFFS_" +
+	"BASE__PIXEL_FORMAT__PLANAR (((uint32_t)1) << 31)\n#define WUFFS_BASE__PIXEL_FORMAT__PREMULTIPLIED_ALPHA (((uint32_t)1) << 30)\n\n#define WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__NONE 0x00\n#define WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__RGBA 0x01\n#define WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__BGRA 0x02\n#define WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__Y 0x03\n#define WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__YCBCR 0x04\n\n// wuffs_base__pixel_subsampling encodes the chroma subsampling factors for a\n// planar YCbCr wuffs_base__pixel_format. It is meaningless for packed pixel\n// formats.\ntypedef uint32_t wuffs_base__pixel_subsampling;\n\n#define WUFFS_BASE__PIXEL_SUBSAMPLING__444 0\n#define WUFFS_BASE__PIXEL_SUBSAMPLING__422 1\n#define WUFFS_BASE__PIXEL_SUBSAMPLING__420 2\n#define WUFFS_BASE__PIXEL_SUBSAMPLING__411 3\n\n// WUFFS_BASE__PIXEL_FORMAT__MAX_INCL_PLANE_COUNT is the largest" +
+	" number of\n// planes (e.g. 3 for 4:2:0 YCbCr) that wuffs_base__image_config can describe.\n#define WUFFS_BASE__PIXEL_FORMAT__MAX_INCL_PLANE_COUNT 3\n\ntypedef struct {\n  // Do not access the private_impl's fields directly. There is no API/ABI\n  // compatibility or safety guarantee if you do so.\n  struct {\n    uint32_t flags;\n    uint32_t w;\n    uint32_t h;\n    wuffs_base__pixel_format pixfmt;\n    wuffs_base__pixel_subsampling subsampling;\n  } private_impl;\n} wuffs_base__image_config;\n\nstatic inline void wuffs_base__image_config__invalidate(\n    wuffs_base__image_config* c) {\n  if (c) {\n    *c = ((wuffs_base__image_config){});\n  }\n}\n\nstatic inline bool wuffs_base__image_config__valid(\n    wuffs_base__image_config* c) {\n  if (!c || !(c->private_impl.flags & 1)) {\n    return false;\n  }\n  uint64_t wh = ((uint64_t)c->private_impl.w) * ((uint64_t)c->private_impl.h);\n " +
+	" // pixbuf_size sums, across up to 3 planes (planar YCbCr, none of them\n  // subsampled smaller than the full image), a row-stride (rounded up to\n  // 4 bytes) times a height, at up to 8 bytes per pixel (4 channels, 16\n  // bits each). Reject wh up front if 24*wh (a conservative upper bound\n  // on that total, ignoring the negligible rounding) would overflow\n  // size_t, so that stride and pixbuf_size can do unchecked arithmetic.\n  return wh <= (((uint64_t)SIZE_MAX) / 24);\n}\n\nstatic inline uint32_t wuffs_base__image_config__width(\n    wuffs_base__image_config* c) {\n  return wuffs_base__image_config__valid(c) ? c->private_impl.w : 0;\n}\n\nstatic inline uint32_t wuffs_base__image_config__height(\n    wuffs_base__image_config* c) {\n  return wuffs_base__image_config__valid(c) ? c->private_impl.h : 0;\n}\n\nstatic inline wuffs_base__pixel_format wuffs_base__image_config__pixel_fo" +
+	"rmat(\n    wuffs_base__image_config* c) {\n  return wuffs_base__image_config__valid(c) ? c->private_impl.pixfmt : 0;\n}\n\n// wuffs_base__image_config__plane_count returns the number of planes: 1 for\n// packed pixel formats, or (depending on the subsampling) 1 to\n// WUFFS_BASE__PIXEL_FORMAT__MAX_INCL_PLANE_COUNT for planar YCbCr.\nstatic inline uint32_t wuffs_base__image_config__plane_count(\n    wuffs_base__image_config* c) {\n  if (!wuffs_base__image_config__valid(c)) {\n    return 0;\n  }\n  if (c->private_impl.pixfmt & WUFFS_BASE__PIXEL_FORMAT__PLANAR) {\n    return 3;\n  }\n  return 1;\n}\n\n// wuffs_base__image_config__bytes_per_pixel returns, for the given plane,\n// how many bytes each of its samples occupies. For subsampled chroma planes,\n// this is still the size of a single Cb or Cr sample, not a pixel's worth.\nstatic inline uint32_t wuffs_base__image_config__bytes_per_pix" +
+	"el(\n    wuffs_base__image_config* c,\n    uint32_t plane) {\n  if (!wuffs_base__image_config__valid(c) ||\n      (plane >= wuffs_base__image_config__plane_count(c))) {\n    return 0;\n  }\n  uint32_t bits = (c->private_impl.pixfmt >> 8) & 0xFF;\n  if (bits == 0) {\n    bits = 8;\n  }\n  uint32_t channels = 1;\n  uint32_t order = c->private_impl.pixfmt & 0xFF;\n  if ((order == WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__RGBA) ||\n      (order == WUFFS_BASE__PIXEL_FORMAT__CHANNEL_ORDER__BGRA)) {\n    channels = 4;\n  }\n  return channels * ((bits + 7) / 8);\n}\n\n// wuffs_base__image_config__plane_width_height gives, for the given plane,\n// its dimensions in samples, accounting for chroma subsampling.\nstatic inline void wuffs_base__image_config__plane_width_height(\n    wuffs_base__image_config* c,\n    uint32_t plane,\n    uint32_t* width,\n    uint32_t* height) {\n  uint32_t w = wuffs_ba" +
+	"se__image_config__width(c);\n  uint32_t h = wuffs_base__image_config__height(c);\n  if ((plane > 0) && (c->private_impl.pixfmt & WUFFS_BASE__PIXEL_FORMAT__PLANAR)) {\n    switch (c->private_impl.subsampling) {\n      case WUFFS_BASE__PIXEL_SUBSAMPLING__422:\n        w = (w + 1) / 2;\n        break;\n      case WUFFS_BASE__PIXEL_SUBSAMPLING__420:\n        w = (w + 1) / 2;\n        h = (h + 1) / 2;\n        break;\n      case WUFFS_BASE__PIXEL_SUBSAMPLING__411:\n        w = (w + 3) / 4;\n        break;\n    }\n  }\n  if (width) {\n    *width = w;\n  }\n  if (height) {\n    *height = h;\n  }\n}\n\n// wuffs_base__image_config__stride returns the row-stride, in bytes, of the\n// given plane, rounded up to a 4-byte alignment.\nstatic inline size_t wuffs_base__image_config__stride(\n    wuffs_base__image_config* c,\n    uint32_t plane) {\n  uint32_t w = 0;\n  wuffs_base__image_config__plane_wid" +
+	"th_height(c, plane, &w, NULL);\n  size_t stride = ((size_t)w) * wuffs_base__image_config__bytes_per_pixel(c, plane);\n  return (stride + 3) & ~((size_t)3);\n}\n\n// wuffs_base__image_config__plane_offset returns the byte offset, from the\n// start of the pixel buffer, at which the given plane begins.\nstatic inline size_t wuffs_base__image_config__plane_offset(\n    wuffs_base__image_config* c,\n    uint32_t plane) {\n  size_t offset = 0;\n  for (uint32_t p = 0; p < plane; p++) {\n    uint32_t h = 0;\n    wuffs_base__image_config__plane_width_height(c, p, NULL, &h);\n    offset += wuffs_base__image_config__stride(c, p) * h;\n  }\n  return offset;\n}\n\n// wuffs_base__image_config__pixbuf_size returns the total number of bytes\n// required to hold every plane (including any row-stride padding) of the\n// pixel buffer described by c.\nstatic inline size_t wuffs_base__image_config__pixbuf_s" +
+	"ize(\n    wuffs_base__image_config* c) {\n  if (!wuffs_base__image_config__valid(c)) {\n    return 0;\n  }\n  uint32_t plane_count = wuffs_base__image_config__plane_count(c);\n  uint32_t last = plane_count ? plane_count - 1 : 0;\n  uint32_t last_h = 0;\n  wuffs_base__image_config__plane_width_height(c, last, NULL, &last_h);\n  return wuffs_base__image_config__plane_offset(c, last) +\n         wuffs_base__image_config__stride(c, last) * last_h;\n}\n\nstatic inline void wuffs_base__image_config__initialize(\n    wuffs_base__image_config* c,\n    uint32_t width,\n    uint32_t height,\n    wuffs_base__pixel_format pixfmt,\n    wuffs_base__pixel_subsampling subsampling) {\n  if (!c) {\n    return;\n  }\n  c->private_impl.flags = 1;\n  c->private_impl.w = width;\n  c->private_impl.h = height;\n  c->private_impl.pixfmt = pixfmt;\n  c->private_impl.subsampling = subsampling;\n}\n\n#endif  // WUF" +
+	"FS_BASE_HEADER_H\n"
 
 const baseImpl = "" +
-	"// Copyright 2017 The Wuffs Authors.\n//\n// Licensed under the Apache License, Version 2.0 (the \"License\");\n// you may not use this file except in compliance with the License.\n// You may obtain a copy of the License at\n//\n//    https://www.apache.org/licenses/LICENSE-2.0\n//\n// Unless required by applicable law or agreed to in writing, software\n// distributed under the License is distributed on an \"AS IS\" BASIS,\n// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.\n// See the License for the specific language governing permissions and\n// limitations under the License.\n\n// wuffs_base__empty_struct is used when a Wuffs function returns an empty\n// struct. In C, if a function f returns void, you can't say \"x = f()\", but in\n// Wuffs, if a function g returns empty, you can say \"y = g()\".\ntypedef struct {\n} wuffs_base__empty_struct;\n\n#define WUFFS_BASE__IGNORE_POTENTIALLY_UNUSED_VARIABLE(x) (void)(x)\n\n// WUFFS_BASE__MAGIC is a magic number to check that initializers are called.\n// It's not foolp" +
-	"roof, given C doesn't automatically zero memory before use,\n// but it should catch 99.99% of cases.\n//\n// Its (non-zero) value is arbitrary, based on md5sum(\"wuffs\").\n#define WUFFS_BASE__MAGIC (0x3CCB6C71U)\n\n// WUFFS_BASE__ALREADY_ZEROED is passed from a container struct's initializer\n// to a containee struct's initializer when the container has already zeroed\n// the containee's memory.\n//\n// Its (non-zero) value is arbitrary, based on md5sum(\"zeroed\").\n#define WUFFS_BASE__ALREADY_ZEROED (0x68602EF1U)\n\n// Denote intentional fallthroughs for -Wimplicit-fallthrough.\n//\n// The order matters here. Clang also defines \"__GNUC__\".\n#if defined(__clang__) && __cplusplus >= 201103L\n#define WUFFS_BASE__FALLTHROUGH [[clang::fallthrough]]\n#elif !defined(__clang__) && defined(__GNUC__) && (__GNUC__ >= 7)\n#define WUFFS_BASE__FALLTHROUGH __attribute__((fallthrough))\n#else\n#define WUFFS_BASE__FALLTHROUGH\n#endif\n\n// Use switch cases for coroutine suspension points, similar to the technique\n// in https://www.chiark.greenend.org" +
-	".uk/~sgtatham/coroutines.html\n//\n// We use trivial macros instead of an explicit assignment and case statement\n// so that clang-format doesn't get confused by the unusual \"case\"s.\n#define WUFFS_BASE__COROUTINE_SUSPENSION_POINT_0 case 0:;\n#define WUFFS_BASE__COROUTINE_SUSPENSION_POINT(n) \\\n  coro_susp_point = n;                            \\\n  WUFFS_BASE__FALLTHROUGH;                        \\\n  case n:;\n\n#define WUFFS_BASE__COROUTINE_SUSPENSION_POINT_MAYBE_SUSPEND(n) \\\n  if (status < 0) {                                             \\\n    goto exit;                                                  \\\n  } else if (status == 0) {                                     \\\n    goto ok;                                                    \\\n  }                                                             \\\n  coro_susp_point = n;                                          \\\n  goto suspend;                                                 \\\n  case n:;\n\n// Clang also defines \"__GNUC__\".\n#if defined(__GNUC__)\n#define WUFFS_BASE__LI" +
-	"KELY(expr) (__builtin_expect(!!(expr), 1))\n#define WUFFS_BASE__UNLIKELY(expr) (__builtin_expect(!!(expr), 0))\n#else\n#define WUFFS_BASE__LIKELY(expr) (expr)\n#define WUFFS_BASE__UNLIKELY(expr) (expr)\n#endif\n\n// Uncomment this #include for printf-debugging.\n// #include <stdio.h>\n\n// ---------------- Static Inline Functions\n//\n// The helpers below are functions, instead of macros, because their arguments\n// can be an expression that we shouldn't evaluate more than once.\n//\n// They are in base-impl.h and hence copy/pasted into every generated C file,\n// instead of being in some \"base.c\" file, since a design goal is that users of\n// the generated C code can often just #include a single .c file, such as\n// \"gif.c\", without having to additionally include or otherwise build and link\n// a \"base.c\" file.\n//\n// They are static, so that linking multiple wuffs .o files won't complain about\n// duplicate function definitions.\n//\n// They are explicitly marked inline, even if modern compilers don't use the\n// inline attribute " +
-	"to guide optimizations such as inlining, to avoid the\n// -Wunused-function warning, and we like to compile with -Wall -Werror.\n\nstatic inline uint16_t wuffs_base__load_u16be(uint8_t* p) {\n  return ((uint16_t)(p[0]) << 8) | ((uint16_t)(p[1]) << 0);\n}\n\nstatic inline uint16_t wuffs_base__load_u16le(uint8_t* p) {\n  return ((uint16_t)(p[0]) << 0) | ((uint16_t)(p[1]) << 8);\n}\n\nstatic inline uint32_t wuffs_base__load_u32be(uint8_t* p) {\n  return ((uint32_t)(p[0]) << 24) | ((uint32_t)(p[1]) << 16) |\n         ((uint32_t)(p[2]) << 8) | ((uint32_t)(p[3]) << 0);\n}\n\nstatic inline uint32_t wuffs_base__load_u32le(uint8_t* p) {\n  return ((uint32_t)(p[0]) << 0) | ((uint32_t)(p[1]) << 8) |\n         ((uint32_t)(p[2]) << 16) | ((uint32_t)(p[3]) << 24);\n}\n\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__subslice_i(\n    wuffs_base__slice_u8 s,\n    uint64_t i) {\n  if ((i <= SIZE_MAX) && (i <= s.len)) {\n    return ((wuffs_base__slice_u8){\n        .ptr = s.ptr + i,\n        .len = s.len - i,\n    });\n  }\n  return ((wuffs_base_" +
-	"_slice_u8){});\n}\n\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__subslice_j(\n    wuffs_base__slice_u8 s,\n    uint64_t j) {\n  if ((j <= SIZE_MAX) && (j <= s.len)) {\n    return ((wuffs_base__slice_u8){.ptr = s.ptr, .len = j});\n  }\n  return ((wuffs_base__slice_u8){});\n}\n\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__subslice_ij(\n    wuffs_base__slice_u8 s,\n    uint64_t i,\n    uint64_t j) {\n  if ((i <= j) && (j <= SIZE_MAX) && (j <= s.len)) {\n    return ((wuffs_base__slice_u8){\n        .ptr = s.ptr + i,\n        .len = j - i,\n    });\n  }\n  return ((wuffs_base__slice_u8){});\n}\n\n// wuffs_base__slice_u8__prefix returns up to the first up_to bytes of s.\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__prefix(\n    wuffs_base__slice_u8 s,\n    uint64_t up_to) {\n  if ((uint64_t)(s.len) > up_to) {\n    s.len = up_to;\n  }\n  return s;\n}\n\n// wuffs_base__slice_u8__suffix returns up to the last up_to bytes of s.\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8_suffix(\n    wuffs_base__slice_u8 " +
-	"s,\n    uint64_t up_to) {\n  if ((uint64_t)(s.len) > up_to) {\n    s.ptr += (uint64_t)(s.len) - up_to;\n    s.len = up_to;\n  }\n  return s;\n}\n\n// wuffs_base__slice_u8__copy_from_slice calls memmove(dst.ptr, src.ptr,\n// length) where length is the minimum of dst.len and src.len.\n//\n// Passing a wuffs_base__slice_u8 with all fields NULL or zero (a valid, empty\n// slice) is valid and results in a no-op.\nstatic inline uint64_t wuffs_base__slice_u8__copy_from_slice(\n    wuffs_base__slice_u8 dst,\n    wuffs_base__slice_u8 src) {\n  size_t length = dst.len < src.len ? dst.len : src.len;\n  if (length > 0) {\n    memmove(dst.ptr, src.ptr, length);\n  }\n  return length;\n}\n\nstatic inline uint32_t wuffs_base__writer1__copy_from_history32(\n    uint8_t** ptr_ptr,\n    uint8_t* start,  // May be NULL, meaning an unmarked writer1.\n    uint8_t* end,\n    uint32_t distance,\n    uint32_t length) {\n  if (!start || !distance) {\n    return 0;\n  }\n  uint8_t* ptr = *ptr_ptr;\n  if ((size_t)(ptr - start) < (size_t)(distance)) {\n    return 0;\n  }" +
-	"\n  start = ptr - distance;\n  size_t n = end - ptr;\n  if ((size_t)(length) > n) {\n    length = n;\n  } else {\n    n = length;\n  }\n  // TODO: unrolling by 3 seems best for the std/deflate benchmarks, but that\n  // is mostly because 3 is the minimum length for the deflate format. This\n  // function implementation shouldn't overfit to that one format. Perhaps the\n  // copy_from_history32 Wuffs method should also take an unroll hint argument,\n  // and the cgen can look if that argument is the constant expression '3'.\n  //\n  // See also wuffs_base__writer1__copy_from_history32__bco below.\n  //\n  // Alternatively, or additionally, have a sloppy_copy_from_history32 method\n  // that copies 8 bytes at a time, possibly writing more than length bytes?\n  for (; n >= 3; n -= 3) {\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n  }\n  for (; n; n--) {\n    *ptr++ = *start++;\n  }\n  *ptr_ptr = ptr;\n  return length;\n}\n\n// wuffs_base__writer1__copy_from_history32__bco is a Bounds Check Optimized\n// version of " +
-	"the wuffs_base__writer1__copy_from_history32 function above. The\n// caller needs to prove that:\n//  - start    != NULL\n//  - distance >  0\n//  - distance <= (*ptr_ptr - start)\n//  - length   <= (end      - *ptr_ptr)\nstatic inline uint32_t wuffs_base__writer1__copy_from_history32__bco(\n    uint8_t** ptr_ptr,\n    uint8_t* start,\n    uint8_t* end,\n    uint32_t distance,\n    uint32_t length) {\n  uint8_t* ptr = *ptr_ptr;\n  start = ptr - distance;\n  uint32_t n = length;\n  for (; n >= 3; n -= 3) {\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n  }\n  for (; n; n--) {\n    *ptr++ = *start++;\n  }\n  *ptr_ptr = ptr;\n  return length;\n}\n\nstatic inline uint32_t wuffs_base__writer1__copy_from_reader32(\n    uint8_t** ptr_wptr,\n    uint8_t* wend,\n    uint8_t** ptr_rptr,\n    uint8_t* rend,\n    uint32_t length) {\n  uint8_t* wptr = *ptr_wptr;\n  size_t n = length;\n  if (n > wend - wptr) {\n    n = wend - wptr;\n  }\n  uint8_t* rptr = *ptr_rptr;\n  if (n > rend - rptr) {\n    n = rend - rptr;\n  }\n  if (n > 0) {\n    " +
-	"memmove(wptr, rptr, n);\n    *ptr_wptr += n;\n    *ptr_rptr += n;\n  }\n  return n;\n}\n\nstatic inline uint64_t wuffs_base__writer1__copy_from_slice(\n    uint8_t** ptr_wptr,\n    uint8_t* wend,\n    wuffs_base__slice_u8 src) {\n  uint8_t* wptr = *ptr_wptr;\n  size_t n = src.len;\n  if (n > wend - wptr) {\n    n = wend - wptr;\n  }\n  if (n > 0) {\n    memmove(wptr, src.ptr, n);\n    *ptr_wptr += n;\n  }\n  return n;\n}\n\nstatic inline uint32_t wuffs_base__writer1__copy_from_slice32(\n    uint8_t** ptr_wptr,\n    uint8_t* wend,\n    wuffs_base__slice_u8 src,\n    uint32_t length) {\n  uint8_t* wptr = *ptr_wptr;\n  size_t n = src.len;\n  if (n > length) {\n    n = length;\n  }\n  if (n > wend - wptr) {\n    n = wend - wptr;\n  }\n  if (n > 0) {\n    memmove(wptr, src.ptr, n);\n    *ptr_wptr += n;\n  }\n  return n;\n}\n\n// Note that the *__limit and *__mark methods are private (in base-impl.h) not\n// public (in base-header.h). We assume that, at the boundary between user code\n// and Wuffs code, the reader1 and writer1's private_impl fields (including" +
-	"\n// limit and mark) are NULL. Otherwise, some internal assumptions break down.\n// For example, limits could be represented as pointers, even though\n// conceptually they are counts, but that pointer-to-count correspondence\n// becomes invalid if a buffer is re-used (e.g. on resuming a coroutine).\n//\n// Admittedly, some of the Wuffs test code calls these methods, but that test\n// code is still Wuffs code, not user code. Other Wuffs test code modifies\n// private_impl fields directly.\n\nstatic inline wuffs_base__reader1 wuffs_base__reader1__limit(\n    wuffs_base__reader1* o,\n    uint64_t* ptr_to_len) {\n  wuffs_base__reader1 ret = *o;\n  ret.private_impl.limit.ptr_to_len = ptr_to_len;\n  ret.private_impl.limit.next = &o->private_impl.limit;\n  return ret;\n}\n\nstatic inline wuffs_base__empty_struct wuffs_base__reader1__mark(\n    wuffs_base__reader1* o,\n    uint8_t* mark) {\n  o->private_impl.mark = mark;\n  return ((wuffs_base__empty_struct){});\n}\n\n// TODO: static inline wuffs_base__writer1 wuffs_base__writer1__limit()\n\nst" +
-	"atic inline wuffs_base__empty_struct wuffs_base__writer1__mark(\n    wuffs_base__writer1* o,\n    uint8_t* mark) {\n  o->private_impl.mark = mark;\n  return ((wuffs_base__empty_struct){});\n}\n" +
-	""
+	"// Copyright 2017 The Wuffs Authors.\n//\n// Licensed under the Apache License, Version 2.0 (the \"License\");\n// you may not use this file except in compliance with the License.\n// You may obtain a copy of the License at\n//\n//    https://www.apache.org/licenses/LICENSE-2.0\n//\n// Unless required by applicable law or agreed to in writing, software\n// distributed under the License is distributed on an \"AS IS\" BASIS,\n// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.\n// See the License for the specific language governing permissions and\n// limitations under the License.\n\n// wuffs_base__empty_struct is used when a Wuffs function returns an empty\n// struct. In C, if a function f returns void, you can't say \"x = f()\", but in\n// Wuffs, if a function g returns empty, you can say \"y = g()\".\ntypedef struct {\n} wuffs_base__empty_struct;\n\n#define WUFF" +
+	"S_BASE__IGNORE_POTENTIALLY_UNUSED_VARIABLE(x) (void)(x)\n\n// WUFFS_BASE__MAGIC is a magic number to check that initializers are called.\n// It's not foolproof, given C doesn't automatically zero memory before use,\n// but it should catch 99.99% of cases.\n//\n// Its (non-zero) value is arbitrary, based on md5sum(\"wuffs\").\n#define WUFFS_BASE__MAGIC (0x3CCB6C71U)\n\n// WUFFS_BASE__ALREADY_ZEROED is passed from a container struct's initializer\n// to a containee struct's initializer when the container has already zeroed\n// the containee's memory.\n//\n// Its (non-zero) value is arbitrary, based on md5sum(\"zeroed\").\n#define WUFFS_BASE__ALREADY_ZEROED (0x68602EF1U)\n\n// Denote intentional fallthroughs for -Wimplicit-fallthrough.\n//\n// The order matters here. Clang also defines \"__GNUC__\".\n#if defined(__clang__) && __cplusplus >= 201103L\n#define WUFFS_BASE__FALLTHROUGH [[clang::" +
+	"fallthrough]]\n#elif !defined(__clang__) && defined(__GNUC__) && (__GNUC__ >= 7)\n#define WUFFS_BASE__FALLTHROUGH __attribute__((fallthrough))\n#else\n#define WUFFS_BASE__FALLTHROUGH\n#endif\n\n// Use switch cases for coroutine suspension points, similar to the technique\n// in https://www.chiark.greenend.org.uk/~sgtatham/coroutines.html\n//\n// We use trivial macros instead of an explicit assignment and case statement\n// so that clang-format doesn't get confused by the unusual \"case\"s.\n#define WUFFS_BASE__COROUTINE_SUSPENSION_POINT_0 case 0:;\n#define WUFFS_BASE__COROUTINE_SUSPENSION_POINT(n) \\\n  coro_susp_point = n;                            \\\n  WUFFS_BASE__FALLTHROUGH;                        \\\n  case n:;\n\n#define WUFFS_BASE__COROUTINE_SUSPENSION_POINT_MAYBE_SUSPEND(n) \\\n  if (status < 0) {                                             \\\n    goto exit;                   " +
+	"                               \\\n  } else if (status == 0) {                                     \\\n    goto ok;                                                    \\\n  }                                                             \\\n  coro_susp_point = n;                                          \\\n  goto suspend;                                                 \\\n  case n:;\n\n// Clang also defines \"__GNUC__\".\n#if defined(__GNUC__)\n#define WUFFS_BASE__LIKELY(expr) (__builtin_expect(!!(expr), 1))\n#define WUFFS_BASE__UNLIKELY(expr) (__builtin_expect(!!(expr), 0))\n#else\n#define WUFFS_BASE__LIKELY(expr) (expr)\n#define WUFFS_BASE__UNLIKELY(expr) (expr)\n#endif\n\n// Uncomment this #include for printf-debugging.\n// #include <stdio.h>\n\n// ---------------- Static Inline Functions\n//\n// The helpers below are functions, instead of macros, because their arguments\n// can be an" +
+	" expression that we shouldn't evaluate more than once.\n//\n// They are in base-impl.h and hence copy/pasted into every generated C file,\n// instead of being in some \"base.c\" file, since a design goal is that users of\n// the generated C code can often just #include a single .c file, such as\n// \"gif.c\", without having to additionally include or otherwise build and link\n// a \"base.c\" file.\n//\n// They are static, so that linking multiple wuffs .o files won't complain about\n// duplicate function definitions.\n//\n// They are explicitly marked inline, even if modern compilers don't use the\n// inline attribute to guide optimizations such as inlining, to avoid the\n// -Wunused-function warning, and we like to compile with -Wall -Werror.\n\n// ---------------- Guarded Buffers\n//\n// WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS is an opt-in mode, meant for fuzzing\n// and other hardened te" +
+	"sting, that places a wuffs_base__buf1's backing\n// storage immediately before a PROT_NONE guard page. Any out-of-bounds write\n// by generated code then faults (SIGSEGV) at the point of the overflow,\n// instead of silently corrupting whatever heap memory happened to be\n// adjacent. It is not meant for production use: it wastes up to one page per\n// buffer and mmap/munmap are comparatively slow.\n#if defined(WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS)\n\n#include <assert.h>\n#include <sys/mman.h>\n#include <unistd.h>\n\n// wuffs_base__buf1__new_guarded allocates a wuffs_base__buf1 of the given\n// length. On failure (len is 0, or the mmap or mprotect calls fail), the\n// returned buf1 is the zero value.\nstatic inline wuffs_base__buf1 wuffs_base__buf1__new_guarded(size_t len) {\n  if (len == 0) {\n    return ((wuffs_base__buf1){});\n  }\n  size_t page_size = (size_t)(sysconf(_SC_PAGESIZE));" +
+	"\n  size_t data_size = (len + page_size - 1) & ~(page_size - 1);\n  size_t total_size = data_size + page_size;\n  void* base = mmap(NULL, total_size, PROT_READ | PROT_WRITE,\n                     MAP_PRIVATE | MAP_ANONYMOUS, -1, 0);\n  if (base == MAP_FAILED) {\n    return ((wuffs_base__buf1){});\n  }\n  if (mprotect(((uint8_t*)base) + data_size, page_size, PROT_NONE) != 0) {\n    munmap(base, total_size);\n    return ((wuffs_base__buf1){});\n  }\n  // Butt the buffer up against the guard page, so that the first\n  // out-of-bounds byte is the first byte of that page, regardless of any\n  // rounding slack at the front of the mapping.\n  return ((wuffs_base__buf1){\n      .ptr = ((uint8_t*)base) + data_size - len,\n      .len = len,\n  });\n}\n\n// wuffs_base__buf1__free_guarded releases a wuffs_base__buf1 allocated by\n// wuffs_base__buf1__new_guarded. Calling it on any other buf1, or c" +
+	"alling it\n// twice on the same buf1, is undefined behavior.\nstatic inline void wuffs_base__buf1__free_guarded(wuffs_base__buf1* b) {\n  if (!b || !b->ptr) {\n    return;\n  }\n  size_t page_size = (size_t)(sysconf(_SC_PAGESIZE));\n  size_t data_size = (b->len + page_size - 1) & ~(page_size - 1);\n  uint8_t* base = (b->ptr + b->len) - data_size;\n  munmap(base, data_size + page_size);\n  *b = ((wuffs_base__buf1){});\n}\n\n#endif  // WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS\n\n// ---------------- Static Inline Functions, continued\n//\n// The __le (little-endian) and __be (big-endian) loads and stores below\n// each have three implementations, tried in order: a memcpy that's correct\n// as-is when the host's byte order already matches, a memcpy plus a single\n// MOV-then-BSWAP (under GCC and Clang) when it's the opposite, and finally\n// a fully portable byte-by-byte shift/OR fallback on hos" +
+	"ts whose byte\n// order this preprocessor can't determine at all.\n#if defined(__GNUC__)\n#define WUFFS_BASE__BSWAP_U16(x) (__builtin_bswap16(x))\n#define WUFFS_BASE__BSWAP_U32(x) (__builtin_bswap32(x))\n#define WUFFS_BASE__BSWAP_U64(x) (__builtin_bswap64(x))\n#endif\n\nstatic inline uint16_t wuffs_base__load_u16be(uint8_t* p) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__)\n  uint16_t x;\n  memcpy(&x, p, 2);\n  return x;\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U16)\n  uint16_t x;\n  memcpy(&x, p, 2);\n  return WUFFS_BASE__BSWAP_U16(x);\n#else\n  return ((uint16_t)(p[0]) << 8) | ((uint16_t)(p[1]) << 0);\n#endif\n}\n\nstatic inline uint16_t wuffs_base__load_u16le(uint8_t* p) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__)\n  uint16_t x;\n  memcpy(&x, p, 2);\n" +
+	"  return x;\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U16)\n  uint16_t x;\n  memcpy(&x, p, 2);\n  return WUFFS_BASE__BSWAP_U16(x);\n#else\n  return ((uint16_t)(p[0]) << 0) | ((uint16_t)(p[1]) << 8);\n#endif\n}\n\nstatic inline uint32_t wuffs_base__load_u32be(uint8_t* p) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__)\n  uint32_t x;\n  memcpy(&x, p, 4);\n  return x;\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U32)\n  uint32_t x;\n  memcpy(&x, p, 4);\n  return WUFFS_BASE__BSWAP_U32(x);\n#else\n  return ((uint32_t)(p[0]) << 24) | ((uint32_t)(p[1]) << 16) |\n         ((uint32_t)(p[2]) << 8) | ((uint32_t)(p[3]) << 0);\n#endif\n}\n\nstatic inline uint32_t wuffs_base__load_u32le(uint8_t* p) {\n#if defined(__BYTE_ORDER__) && (__BY" +
+	"TE_ORDER__ == __ORDER_LITTLE_ENDIAN__)\n  uint32_t x;\n  memcpy(&x, p, 4);\n  return x;\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U32)\n  uint32_t x;\n  memcpy(&x, p, 4);\n  return WUFFS_BASE__BSWAP_U32(x);\n#else\n  return ((uint32_t)(p[0]) << 0) | ((uint32_t)(p[1]) << 8) |\n         ((uint32_t)(p[2]) << 16) | ((uint32_t)(p[3]) << 24);\n#endif\n}\n\nstatic inline uint64_t wuffs_base__load_u64be(uint8_t* p) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__)\n  uint64_t x;\n  memcpy(&x, p, 8);\n  return x;\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U64)\n  uint64_t x;\n  memcpy(&x, p, 8);\n  return WUFFS_BASE__BSWAP_U64(x);\n#else\n  return ((uint64_t)(p[0]) << 56) | ((uint64_t)(p[1]) << 48) |\n         ((uint64_t)(p[2]) << 4" +
+	"0) | ((uint64_t)(p[3]) << 32) |\n         ((uint64_t)(p[4]) << 24) | ((uint64_t)(p[5]) << 16) |\n         ((uint64_t)(p[6]) << 8) | ((uint64_t)(p[7]) << 0);\n#endif\n}\n\nstatic inline uint64_t wuffs_base__load_u64le(uint8_t* p) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__)\n  uint64_t x;\n  memcpy(&x, p, 8);\n  return x;\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U64)\n  uint64_t x;\n  memcpy(&x, p, 8);\n  return WUFFS_BASE__BSWAP_U64(x);\n#else\n  return ((uint64_t)(p[0]) << 0) | ((uint64_t)(p[1]) << 8) |\n         ((uint64_t)(p[2]) << 16) | ((uint64_t)(p[3]) << 24) |\n         ((uint64_t)(p[4]) << 32) | ((uint64_t)(p[5]) << 40) |\n         ((uint64_t)(p[6]) << 48) | ((uint64_t)(p[7]) << 56);\n#endif\n}\n\n// wuffs_base__load_uNle_at is a convenience form of wuffs_base__load_uNle\n// th" +
+	"at takes a base pointer and a byte offset, instead of requiring the\n// caller to first compute p + off.\nstatic inline uint16_t wuffs_base__load_u16le_at(uint8_t* p, size_t off) {\n  return wuffs_base__load_u16le(p + off);\n}\n\nstatic inline uint32_t wuffs_base__load_u32le_at(uint8_t* p, size_t off) {\n  return wuffs_base__load_u32le(p + off);\n}\n\nstatic inline uint64_t wuffs_base__load_u64le_at(uint8_t* p, size_t off) {\n  return wuffs_base__load_u64le(p + off);\n}\n\nstatic inline void wuffs_base__store_u16be(uint8_t* p, uint16_t x) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__)\n  memcpy(p, &x, 2);\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U16)\n  x = WUFFS_BASE__BSWAP_U16(x);\n  memcpy(p, &x, 2);\n#else\n  p[0] = (uint8_t)(x >> 8);\n  p[1] = (uint8_t)(x >> 0);\n#endif\n}\n\nstatic" +
+	" inline void wuffs_base__store_u16le(uint8_t* p, uint16_t x) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__)\n  memcpy(p, &x, 2);\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U16)\n  x = WUFFS_BASE__BSWAP_U16(x);\n  memcpy(p, &x, 2);\n#else\n  p[0] = (uint8_t)(x >> 0);\n  p[1] = (uint8_t)(x >> 8);\n#endif\n}\n\nstatic inline void wuffs_base__store_u32be(uint8_t* p, uint32_t x) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__)\n  memcpy(p, &x, 4);\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U32)\n  x = WUFFS_BASE__BSWAP_U32(x);\n  memcpy(p, &x, 4);\n#else\n  p[0] = (uint8_t)(x >> 24);\n  p[1] = (uint8_t)(x >> 16);\n  p[2] = (uint8_t)(x >> 8);\n  p[3] = (uint8_t)(x >> 0);\n#endif\n}\n\nstatic inline " +
+	"void wuffs_base__store_u32le(uint8_t* p, uint32_t x) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__)\n  memcpy(p, &x, 4);\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U32)\n  x = WUFFS_BASE__BSWAP_U32(x);\n  memcpy(p, &x, 4);\n#else\n  p[0] = (uint8_t)(x >> 0);\n  p[1] = (uint8_t)(x >> 8);\n  p[2] = (uint8_t)(x >> 16);\n  p[3] = (uint8_t)(x >> 24);\n#endif\n}\n\nstatic inline void wuffs_base__store_u64be(uint8_t* p, uint64_t x) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__)\n  memcpy(p, &x, 8);\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U64)\n  x = WUFFS_BASE__BSWAP_U64(x);\n  memcpy(p, &x, 8);\n#else\n  p[0] = (uint8_t)(x >> 56);\n  p[1] = (uint8_t)(x >> 48);\n  p[2] = (uint8_t)(x >> 40);\n  p" +
+	"[3] = (uint8_t)(x >> 32);\n  p[4] = (uint8_t)(x >> 24);\n  p[5] = (uint8_t)(x >> 16);\n  p[6] = (uint8_t)(x >> 8);\n  p[7] = (uint8_t)(x >> 0);\n#endif\n}\n\nstatic inline void wuffs_base__store_u64le(uint8_t* p, uint64_t x) {\n#if defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_LITTLE_ENDIAN__)\n  memcpy(p, &x, 8);\n#elif defined(__BYTE_ORDER__) && (__BYTE_ORDER__ == __ORDER_BIG_ENDIAN__) && \\\n    defined(WUFFS_BASE__BSWAP_U64)\n  x = WUFFS_BASE__BSWAP_U64(x);\n  memcpy(p, &x, 8);\n#else\n  p[0] = (uint8_t)(x >> 0);\n  p[1] = (uint8_t)(x >> 8);\n  p[2] = (uint8_t)(x >> 16);\n  p[3] = (uint8_t)(x >> 24);\n  p[4] = (uint8_t)(x >> 32);\n  p[5] = (uint8_t)(x >> 40);\n  p[6] = (uint8_t)(x >> 48);\n  p[7] = (uint8_t)(x >> 56);\n#endif\n}\n\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__subslice_i(\n    wuffs_base__slice_u8 s,\n    uint64_t i) {\n  if ((i <= SIZE_MAX) && (i " +
+	"<= s.len)) {\n    return ((wuffs_base__slice_u8){\n        .ptr = s.ptr + i,\n        .len = s.len - i,\n    });\n  }\n  return ((wuffs_base__slice_u8){});\n}\n\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__subslice_j(\n    wuffs_base__slice_u8 s,\n    uint64_t j) {\n  if ((j <= SIZE_MAX) && (j <= s.len)) {\n    return ((wuffs_base__slice_u8){.ptr = s.ptr, .len = j});\n  }\n  return ((wuffs_base__slice_u8){});\n}\n\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__subslice_ij(\n    wuffs_base__slice_u8 s,\n    uint64_t i,\n    uint64_t j) {\n  if ((i <= j) && (j <= SIZE_MAX) && (j <= s.len)) {\n    return ((wuffs_base__slice_u8){\n        .ptr = s.ptr + i,\n        .len = j - i,\n    });\n  }\n  return ((wuffs_base__slice_u8){});\n}\n\n// wuffs_base__slice_u8__prefix returns up to the first up_to bytes of s.\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8__pr" +
+	"efix(\n    wuffs_base__slice_u8 s,\n    uint64_t up_to) {\n  if ((uint64_t)(s.len) > up_to) {\n    s.len = up_to;\n  }\n  return s;\n}\n\n// wuffs_base__slice_u8__suffix returns up to the last up_to bytes of s.\nstatic inline wuffs_base__slice_u8 wuffs_base__slice_u8_suffix(\n    wuffs_base__slice_u8 s,\n    uint64_t up_to) {\n  if ((uint64_t)(s.len) > up_to) {\n    s.ptr += (uint64_t)(s.len) - up_to;\n    s.len = up_to;\n  }\n  return s;\n}\n\n// wuffs_base__slice_u8__copy_from_slice calls memmove(dst.ptr, src.ptr,\n// length) where length is the minimum of dst.len and src.len.\n//\n// Passing a wuffs_base__slice_u8 with all fields NULL or zero (a valid, empty\n// slice) is valid and results in a no-op.\nstatic inline uint64_t wuffs_base__slice_u8__copy_from_slice(\n    wuffs_base__slice_u8 dst,\n    wuffs_base__slice_u8 src) {\n  size_t length = dst.len < src.len ? dst.len : src.len;\n" +
+	"  if (length > 0) {\n    memmove(dst.ptr, src.ptr, length);\n  }\n  return length;\n}\n\nstatic inline uint32_t wuffs_base__writer1__copy_from_history32(\n    uint8_t** ptr_ptr,\n    uint8_t* start,  // May be NULL, meaning an unmarked writer1.\n    uint8_t* end,\n    uint32_t distance,\n    uint32_t length) {\n  if (!start || !distance) {\n    return 0;\n  }\n  uint8_t* ptr = *ptr_ptr;\n  if ((size_t)(ptr - start) < (size_t)(distance)) {\n    return 0;\n  }\n  start = ptr - distance;\n  size_t n = end - ptr;\n  if ((size_t)(length) > n) {\n    length = n;\n  } else {\n    n = length;\n  }\n  // TODO: unrolling by 3 seems best for the std/deflate benchmarks, but that\n  // is mostly because 3 is the minimum length for the deflate format. This\n  // function implementation shouldn't overfit to that one format. Perhaps the\n  // copy_from_history32 Wuffs method should also take an unroll h" +
+	"int argument,\n  // and the cgen can look if that argument is the constant expression '3'.\n  //\n  // See also wuffs_base__writer1__copy_from_history32__bco below.\n  //\n  // Alternatively, or additionally, have a sloppy_copy_from_history32 method\n  // that copies 8 bytes at a time, possibly writing more than length bytes?\n  for (; n >= 3; n -= 3) {\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n  }\n  for (; n; n--) {\n    *ptr++ = *start++;\n  }\n  *ptr_ptr = ptr;\n  return length;\n}\n\n// wuffs_base__writer1__copy_from_history32__bco is a Bounds Check Optimized\n// version of the wuffs_base__writer1__copy_from_history32 function above. The\n// caller needs to prove that:\n//  - start    != NULL\n//  - distance >  0\n//  - distance <= (*ptr_ptr - start)\n//  - length   <= (end      - *ptr_ptr)\nstatic inline uint32_t wuffs_base__writer1__copy_from_history" +
+	"32__bco(\n    uint8_t** ptr_ptr,\n    uint8_t* start,\n    uint8_t* end,\n    uint32_t distance,\n    uint32_t length) {\n  uint8_t* ptr = *ptr_ptr;\n  start = ptr - distance;\n  uint32_t n = length;\n  for (; n >= 3; n -= 3) {\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n    *ptr++ = *start++;\n  }\n  for (; n; n--) {\n    *ptr++ = *start++;\n  }\n  *ptr_ptr = ptr;\n  return length;\n}\n\nstatic inline uint32_t wuffs_base__writer1__copy_from_reader32(\n    uint8_t** ptr_wptr,\n    uint8_t* wend,\n    uint8_t** ptr_rptr,\n    uint8_t* rend,\n    uint32_t length) {\n  uint8_t* wptr = *ptr_wptr;\n  size_t n = length;\n  if (n > wend - wptr) {\n    n = wend - wptr;\n  }\n  uint8_t* rptr = *ptr_rptr;\n  if (n > rend - rptr) {\n    n = rend - rptr;\n  }\n  if (n > 0) {\n    memmove(wptr, rptr, n);\n    *ptr_wptr += n;\n    *ptr_rptr += n;\n  }\n  return n;\n}\n\nstatic inline uint64_t wuf" +
+	"fs_base__writer1__copy_from_slice(\n    uint8_t** ptr_wptr,\n    uint8_t* wend,\n    wuffs_base__slice_u8 src) {\n  uint8_t* wptr = *ptr_wptr;\n  size_t n = src.len;\n  if (n > wend - wptr) {\n    n = wend - wptr;\n  }\n  if (n > 0) {\n    memmove(wptr, src.ptr, n);\n    *ptr_wptr += n;\n  }\n  return n;\n}\n\nstatic inline uint32_t wuffs_base__writer1__copy_from_slice32(\n    uint8_t** ptr_wptr,\n    uint8_t* wend,\n    wuffs_base__slice_u8 src,\n    uint32_t length) {\n  uint8_t* wptr = *ptr_wptr;\n  size_t n = src.len;\n  if (n > length) {\n    n = length;\n  }\n  if (n > wend - wptr) {\n    n = wend - wptr;\n  }\n  if (n > 0) {\n    memmove(wptr, src.ptr, n);\n    *ptr_wptr += n;\n  }\n  return n;\n}\n\n// Note that the *__limit and *__mark methods are private (in base-impl.h) not\n// public (in base-header.h). We assume that, at the boundary between user code\n// and Wuffs code, the" +
+	" reader1 and writer1's private_impl fields (including\n// limit and mark) are NULL. Otherwise, some internal assumptions break down.\n// For example, limits could be represented as pointers, even though\n// conceptually they are counts, but that pointer-to-count correspondence\n// becomes invalid if a buffer is re-used (e.g. on resuming a coroutine).\n//\n// Admittedly, some of the Wuffs test code calls these methods, but that test\n// code is still Wuffs code, not user code. Other Wuffs test code modifies\n// private_impl fields directly.\n\nstatic inline wuffs_base__reader1 wuffs_base__reader1__limit(\n    wuffs_base__reader1* o,\n    uint64_t* ptr_to_len) {\n  wuffs_base__reader1 ret = *o;\n  ret.private_impl.limit.ptr_to_len = ptr_to_len;\n  ret.private_impl.limit.next = &o->private_impl.limit;\n  return ret;\n}\n\nstatic inline wuffs_base__empty_struct wuffs_base__reader1__mark(\n  " +
+	"  wuffs_base__reader1* o,\n    uint8_t* mark) {\n  o->private_impl.mark = mark;\n  return ((wuffs_base__empty_struct){});\n}\n\n// TODO: static inline wuffs_base__writer1 wuffs_base__writer1__limit()\n\nstatic inline wuffs_base__empty_struct wuffs_base__writer1__mark(\n    wuffs_base__writer1* o,\n    uint8_t* mark) {\n  o->private_impl.mark = mark;\n  return ((wuffs_base__empty_struct){});\n}\n"
 
 type template_args_short_read struct {
 	PKGPREFIX string
@@ -46,12 +64,19 @@ type template_args_short_read struct {
 }
 
 func template_short_read(b *buffer, args template_args_short_read) error {
-	b.printf("short_read_%s:\nif (a_%s.buf && a_%s.buf->closed &&\n!a_%s.private_impl.limit.ptr_to_len) {\nstatus = %sERROR_UNEXPECTED_EOF;\ngoto exit;\n}\nstatus = %sSUSPENSION_SHORT_READ;\ngoto suspend;\n",
+	// The WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS assert below is optional: it
+	// only fires when that macro is defined, and otherwise compiles away to
+	// nothing. It re-checks the wuffs_base__buf1 invariant that the fuzzing
+	// harness's guarded buffers are meant to catch violations of.
+	b.printf("short_read_%s:\nif (a_%s.buf && a_%s.buf->closed &&\n!a_%s.private_impl.limit.ptr_to_len) {\nstatus = %sERROR_UNEXPECTED_EOF;\ngoto exit;\n}\n#if defined(WUFFS_CONFIG__ENABLE_GUARDED_BUFFERS)\nif (a_%s.buf) { assert(a_%s.buf->wi <= a_%s.buf->len); }\n#endif\nstatus = %sSUSPENSION_SHORT_READ;\ngoto suspend;\n",
 		args.name,
 		args.name,
 		args.name,
 		args.name,
 		args.PKGPREFIX,
+		args.name,
+		args.name,
+		args.name,
 		args.PKGPREFIX,
 	)
 	return nil